@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+var adminPort = flag.String("admin-port", "7780", "Port on which to serve the admin control-plane API")
+
+// depositsTriggerRequest is the body of POST /deposits/trigger.
+type depositsTriggerRequest struct {
+	Count int `json:"count"`
+}
+
+// depositsScheduleRequest is the body of POST /deposits/schedule: it drips
+// Count deposits onto numDepositsReadyToSend, one per Interval.
+type depositsScheduleRequest struct {
+	Count    int    `json:"count"`
+	Interval string `json:"interval"`
+}
+
+// chainReorgRequest is the body of POST /chain/reorg.
+type chainReorgRequest struct {
+	Depth int `json:"depth"`
+}
+
+// genesisTimeRequest is the body of POST /genesis-time.
+type genesisTimeRequest struct {
+	GenesisTime uint64 `json:"genesis_time"`
+}
+
+// stateResponse is the body returned by GET /state.
+type stateResponse struct {
+	BlockNum               uint64 `json:"block_num"`
+	NumDepositsReadyToSend int    `json:"num_deposits_ready_to_send"`
+	NumDepositsRemaining   int    `json:"num_deposits_remaining"`
+	GenesisTime            uint64 `json:"genesis_time"`
+	ReorgCount             uint64 `json:"reorg_count"`
+	SubscriberCount        int64  `json:"subscriber_count"`
+}
+
+// adminMux builds the control-plane HTTP API served on --admin-port. It
+// exists so the mock can be driven from CI, docker-compose, or a scripted
+// e2e test, none of which have a terminal to feed listenForDepositTrigger.
+func (s *server) adminMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/deposits/trigger", s.handleDepositsTrigger)
+	mux.HandleFunc("/deposits/schedule", s.handleDepositsSchedule)
+	mux.HandleFunc("/chain/reorg", s.handleChainReorg)
+	mux.HandleFunc("/state", s.handleState)
+	mux.HandleFunc("/genesis-time", s.handleGenesisTime)
+	return mux
+}
+
+func (s *server) handleDepositsTrigger(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req depositsTriggerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.WithError(err).Error("Could not decode /deposits/trigger request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := s.triggerDeposits(req.Count); err != nil {
+		log.WithError(err).Error("Could not trigger deposits")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// triggerDeposits marks count more deposits as ready to send, doing the
+// bounds check and the increment of numDepositsReadyToSend under
+// depositsLock. listenForDepositTrigger and the admin handlers all go
+// through it.
+func (s *server) triggerDeposits(count int) error {
+	s.depositsLock.Lock()
+	defer s.depositsLock.Unlock()
+	maxAllowed := len(s.deposits) - s.numDepositsReadyToSend
+	if count < 0 || count > maxAllowed {
+		return fmt.Errorf("cannot trigger %d deposits, max allowed is %d", count, maxAllowed)
+	}
+	s.numDepositsReadyToSend += count
+	s.updateDepositGauges()
+	return nil
+}
+
+func (s *server) handleDepositsSchedule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req depositsScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.WithError(err).Error("Could not decode /deposits/schedule request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	interval, err := time.ParseDuration(req.Interval)
+	if err != nil {
+		log.WithError(err).Error("Could not parse /deposits/schedule interval")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if interval <= 0 {
+		log.Errorf("Could not schedule deposits: interval %s must be positive", interval)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	go s.scheduleDeposits(req.Count, interval)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// scheduleDeposits drip-feeds one deposit onto numDepositsReadyToSend every
+// interval until count deposits have been sent or no more are available.
+func (s *server) scheduleDeposits(count int, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for sent := 0; sent < count; sent++ {
+		<-ticker.C
+		if err := s.triggerDeposits(1); err != nil {
+			log.WithError(err).Warn("Stopping deposit schedule early")
+			return
+		}
+	}
+}
+
+func (s *server) handleChainReorg(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req chainReorgRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.WithError(err).Error("Could not decode /chain/reorg request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	head := s.sim.TriggerReorg(req.Depth)
+	reorgsTotal.Inc()
+	if err := json.NewEncoder(w).Encode(head); err != nil {
+		log.Error(err)
+	}
+}
+
+func (s *server) handleState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	s.depositsLock.Lock()
+	state := stateResponse{
+		BlockNum:               s.sim.Head().Number.Uint64(),
+		NumDepositsReadyToSend: s.numDepositsReadyToSend,
+		NumDepositsRemaining:   len(s.deposits) - s.numDepositsReadyToSend,
+		GenesisTime:            s.genesisTime,
+		ReorgCount:             s.sim.ReorgCount(),
+		SubscriberCount:        atomic.LoadInt64(&s.subscriberCount),
+	}
+	s.depositsLock.Unlock()
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		log.Error(err)
+	}
+}
+
+// handleGenesisTime rewinds sim back to a fresh genesis header at the
+// requested time, so the reset is actually visible to every chain RPC
+// surface rather than only echoed back by GET /state.
+func (s *server) handleGenesisTime(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req genesisTimeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.WithError(err).Error("Could not decode /genesis-time request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.sim.Reset(req.GenesisTime)
+	s.depositsLock.Lock()
+	s.genesisTime = req.GenesisTime
+	s.depositsLock.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
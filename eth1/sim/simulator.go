@@ -0,0 +1,208 @@
+// Package sim implements a minimal, deterministic Ethereum-1 chain
+// simulation: a canonical sequence of block headers that advances on a
+// timer, optionally rewriting its own tail to simulate a reorg, so that
+// every RPC surface of the mock (eth_getBlockByNumber, eth_getBlockByHash,
+// eth_getLogs, and the websocket newHeads stream) can read from the same
+// authoritative source instead of synthesizing inconsistent answers.
+package sim
+
+import (
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Config controls how a ChainSimulator advances and perturbs its chain.
+type Config struct {
+	// BlockTime is the interval between simulated blocks.
+	BlockTime time.Duration
+	// ReorgDepth is how many of the most recent blocks get rewritten with
+	// fresh hashes when a reorg fires. Zero disables reorgs.
+	ReorgDepth int
+	// ReorgProbability is the chance, on every tick, that a reorg of
+	// ReorgDepth blocks occurs.
+	ReorgProbability float64
+	// FinalityDelay is how many blocks must be built on top of a deposit
+	// log's block before eth_getLogs treats it as finalized and visible.
+	FinalityDelay uint64
+}
+
+// ChainSimulator owns the authoritative simulated chain state: one
+// canonical header per block number, indexed by both number and hash.
+type ChainSimulator struct {
+	mu      sync.Mutex
+	cfg     Config
+	rng     *rand.Rand
+	headers []*types.Header // canonical chain, index == block number
+	byHash  map[common.Hash]*types.Header
+	reorgs  uint64
+}
+
+// New creates a ChainSimulator seeded with a genesis header at genesisTime.
+func New(cfg Config, genesisTime uint64) *ChainSimulator {
+	c := &ChainSimulator{
+		cfg:    cfg,
+		rng:    rand.New(rand.NewSource(int64(genesisTime))),
+		byHash: make(map[common.Hash]*types.Header),
+	}
+	genesis := &types.Header{
+		Number: big.NewInt(0),
+		Time:   genesisTime,
+	}
+	c.headers = append(c.headers, genesis)
+	c.byHash[genesis.Hash()] = genesis
+	return c
+}
+
+// Advance appends a new block to the chain, possibly preceded by a reorg
+// of the last ReorgDepth blocks, and returns the new canonical head along
+// with whether a reorg fired on this tick.
+func (c *ChainSimulator) Advance() (head *types.Header, reorged bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cfg.ReorgDepth > 0 && c.cfg.ReorgProbability > 0 && c.rng.Float64() < c.cfg.ReorgProbability {
+		c.reorgLocked(c.cfg.ReorgDepth)
+		reorged = true
+	}
+	return c.appendLocked(), reorged
+}
+
+func (c *ChainSimulator) appendLocked() *types.Header {
+	parent := c.headers[len(c.headers)-1]
+	extra := make([]byte, 8)
+	c.rng.Read(extra)
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number, big.NewInt(1)),
+		Time:       parent.Time + uint64(c.cfg.BlockTime.Seconds()),
+		Extra:      extra,
+	}
+	c.headers = append(c.headers, header)
+	c.byHash[header.Hash()] = header
+	return header
+}
+
+// reorgLocked rewrites the last depth blocks (or as much of the chain as
+// exists past genesis, whichever is shorter) with freshly generated
+// headers, so their hashes change while their block numbers stay the same.
+func (c *ChainSimulator) reorgLocked(depth int) {
+	if depth > len(c.headers)-1 {
+		depth = len(c.headers) - 1
+	}
+	if depth <= 0 {
+		return
+	}
+	start := len(c.headers) - depth
+	for i := start; i < len(c.headers); i++ {
+		delete(c.byHash, c.headers[i].Hash())
+	}
+	c.headers = c.headers[:start]
+	for i := 0; i < depth; i++ {
+		c.appendLocked()
+	}
+	c.reorgs++
+}
+
+// Reset rewinds the chain back to a single genesis header at genesisTime,
+// reseeding the rng the same way New does, so POST /genesis-time can
+// actually change simulated chain behavior instead of only echoing a
+// value nothing reads.
+func (c *ChainSimulator) Reset(genesisTime uint64) *types.Header {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rng = rand.New(rand.NewSource(int64(genesisTime)))
+	genesis := &types.Header{
+		Number: big.NewInt(0),
+		Time:   genesisTime,
+	}
+	c.headers = []*types.Header{genesis}
+	c.byHash = map[common.Hash]*types.Header{genesis.Hash(): genesis}
+	c.reorgs = 0
+	return genesis
+}
+
+// Config returns the simulator's configuration.
+func (c *ChainSimulator) Config() Config {
+	return c.cfg
+}
+
+// Head returns the current canonical head header.
+func (c *ChainSimulator) Head() *types.Header {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.headers[len(c.headers)-1]
+}
+
+// BlockByNumber returns the canonical header at num, or nil if it does not
+// exist, including blocks a reorg has since rewritten away.
+func (c *ChainSimulator) BlockByNumber(num uint64) *types.Header {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if num >= uint64(len(c.headers)) {
+		return nil
+	}
+	return c.headers[num]
+}
+
+// BlockByHash returns the header with the given hash, or nil if it is not
+// part of the current canonical chain.
+func (c *ChainSimulator) BlockByHash(hash common.Hash) *types.Header {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.byHash[hash]
+}
+
+// ReorgCount returns how many reorgs have fired so far.
+func (c *ChainSimulator) ReorgCount() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reorgs
+}
+
+// TriggerReorg forces an immediate reorg of the given depth, independent
+// of ReorgProbability, and returns the new head.
+func (c *ChainSimulator) TriggerReorg(depth int) *types.Header {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reorgLocked(depth)
+	return c.headers[len(c.headers)-1]
+}
+
+// HashesSince returns the canonical block hashes for every block after
+// from up to the current head, in chain order. Used by
+// eth_getFilterChanges to drip-feed a block filter.
+func (c *ChainSimulator) HashesSince(from uint64) []common.Hash {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	head := uint64(len(c.headers) - 1)
+	if from >= head {
+		return nil
+	}
+	hashes := make([]common.Hash, 0, head-from)
+	for i := from + 1; i <= head; i++ {
+		hashes = append(hashes, c.headers[i].Hash())
+	}
+	return hashes
+}
+
+// VisibleLogCount returns how many of the first readyCount deposit logs
+// are old enough to satisfy FinalityDelay given the current head. Deposit
+// logs are assumed to arrive one per block starting at block 1, matching
+// how the mock drip-feeds deposits onto the simulated chain.
+func (c *ChainSimulator) VisibleLogCount(readyCount int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	head := uint64(len(c.headers) - 1)
+	if head <= c.cfg.FinalityDelay {
+		return 0
+	}
+	visible := int(head - c.cfg.FinalityDelay)
+	if visible > readyCount {
+		return readyCount
+	}
+	return visible
+}
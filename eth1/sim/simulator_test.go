@@ -0,0 +1,147 @@
+package sim
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVisibleLogCount(t *testing.T) {
+	c := New(Config{BlockTime: time.Second, FinalityDelay: 2}, 1)
+
+	if got := c.VisibleLogCount(5); got != 0 {
+		t.Fatalf("at genesis: got %d, want 0", got)
+	}
+
+	for i := 0; i < 3; i++ {
+		c.Advance()
+	}
+	// head is now 3, FinalityDelay is 2, so 1 block's worth of logs are
+	// old enough to be visible.
+	if got := c.VisibleLogCount(5); got != 1 {
+		t.Fatalf("after 3 blocks: got %d, want 1", got)
+	}
+	if got := c.VisibleLogCount(0); got != 0 {
+		t.Fatalf("readyCount below visible: got %d, want 0 (clamped to readyCount)", got)
+	}
+
+	c.Advance()
+	c.Advance()
+	// head is now 5, so 3 blocks' worth are old enough, but only 2 are
+	// ready to send.
+	if got := c.VisibleLogCount(2); got != 2 {
+		t.Fatalf("clamped to readyCount: got %d, want 2", got)
+	}
+}
+
+func TestAdvance(t *testing.T) {
+	c := New(Config{BlockTime: time.Second}, 1)
+	genesis := c.Head()
+	if genesis.Number.Uint64() != 0 {
+		t.Fatalf("genesis number = %d, want 0", genesis.Number.Uint64())
+	}
+
+	head, reorged := c.Advance()
+	if reorged {
+		t.Fatalf("Advance with ReorgProbability 0 reported a reorg")
+	}
+	if head.Number.Uint64() != 1 {
+		t.Fatalf("head number = %d, want 1", head.Number.Uint64())
+	}
+	if head.ParentHash != genesis.Hash() {
+		t.Fatalf("head.ParentHash = %s, want genesis hash %s", head.ParentHash, genesis.Hash())
+	}
+	if c.BlockByHash(head.Hash()) == nil {
+		t.Fatalf("BlockByHash did not find the new head")
+	}
+	if c.BlockByNumber(1) == nil {
+		t.Fatalf("BlockByNumber(1) did not find the new head")
+	}
+}
+
+func TestTriggerReorg(t *testing.T) {
+	c := New(Config{BlockTime: time.Second}, 1)
+	for i := 0; i < 5; i++ {
+		c.Advance()
+	}
+	before := c.Head()
+	beforeHashes := make(map[uint64]bool)
+	for n := uint64(3); n <= 5; n++ {
+		beforeHashes[n] = c.BlockByNumber(n) != nil
+	}
+
+	after := c.TriggerReorg(2)
+
+	if after.Number.Uint64() != before.Number.Uint64() {
+		t.Fatalf("reorg changed head number: got %d, want %d", after.Number.Uint64(), before.Number.Uint64())
+	}
+	if after.Hash() == before.Hash() {
+		t.Fatalf("reorg did not change the head hash")
+	}
+	if c.BlockByHash(before.Hash()) != nil {
+		t.Fatalf("old head hash is still resolvable after a reorg rewrote it away")
+	}
+	if c.ReorgCount() != 1 {
+		t.Fatalf("ReorgCount = %d, want 1", c.ReorgCount())
+	}
+
+	// A block number below the rewritten depth must be untouched.
+	if c.BlockByNumber(2).Number.Uint64() != 2 {
+		t.Fatalf("block 2 should be unaffected by a reorg of depth 2")
+	}
+}
+
+func TestTriggerReorgClampsToChainLength(t *testing.T) {
+	c := New(Config{BlockTime: time.Second}, 1)
+	c.Advance()
+	// Requesting a deeper reorg than the chain has non-genesis blocks
+	// must clamp instead of rewriting genesis itself.
+	head := c.TriggerReorg(10)
+	if head.Number.Uint64() != 1 {
+		t.Fatalf("head number after clamped reorg = %d, want 1", head.Number.Uint64())
+	}
+	if c.BlockByNumber(0) == nil || c.BlockByNumber(0).Number.Uint64() != 0 {
+		t.Fatalf("genesis was rewritten by a reorg deeper than the chain")
+	}
+}
+
+func TestReset(t *testing.T) {
+	c := New(Config{BlockTime: time.Second}, 1)
+	c.Advance()
+	c.Advance()
+	c.TriggerReorg(1)
+
+	genesis := c.Reset(42)
+
+	if genesis.Number.Uint64() != 0 {
+		t.Fatalf("Reset genesis number = %d, want 0", genesis.Number.Uint64())
+	}
+	if genesis.Time != 42 {
+		t.Fatalf("Reset genesis time = %d, want 42", genesis.Time)
+	}
+	if c.Head().Hash() != genesis.Hash() {
+		t.Fatalf("Head after Reset is not the new genesis")
+	}
+	if c.ReorgCount() != 0 {
+		t.Fatalf("ReorgCount after Reset = %d, want 0", c.ReorgCount())
+	}
+	if c.BlockByNumber(1) != nil {
+		t.Fatalf("blocks from before Reset are still reachable by number")
+	}
+}
+
+func TestHashesSince(t *testing.T) {
+	c := New(Config{BlockTime: time.Second}, 1)
+	for i := 0; i < 3; i++ {
+		c.Advance()
+	}
+	hashes := c.HashesSince(1)
+	if len(hashes) != 2 {
+		t.Fatalf("got %d hashes, want 2", len(hashes))
+	}
+	if hashes[len(hashes)-1] != c.Head().Hash() {
+		t.Fatalf("last hash is not the current head")
+	}
+	if got := c.HashesSince(c.Head().Number.Uint64()); got != nil {
+		t.Fatalf("HashesSince(head) = %v, want nil", got)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// filterTTL matches geth's default idle timeout for eth_newFilter /
+// eth_newBlockFilter registrations: a filter nobody polls for this long
+// is swept away.
+const filterTTL = 5 * time.Minute
+
+// filterKind distinguishes a log filter (eth_newFilter) from a block
+// filter (eth_newBlockFilter); each advances its cursor over a different
+// axis of the simulated chain.
+type filterKind int
+
+const (
+	logFilterKind filterKind = iota
+	blockFilterKind
+)
+
+// filter is one outstanding eth_newFilter/eth_newBlockFilter registration.
+// logCursor is the next unseen index into server.eth1Logs; blockCursor is
+// the last block number already delivered to a block filter. mu guards
+// logCursor/blockCursor/lastPolled against two concurrent pollers of the
+// same filter id racing on the cursor read-modify-write; filterRegistry.mu
+// only protects the id->*filter map, not the filter's own fields.
+type filter struct {
+	mu          sync.Mutex
+	kind        filterKind
+	criteria    *logFilterCriteria
+	logCursor   int
+	blockCursor uint64
+	lastPolled  time.Time
+}
+
+// filterRegistry tracks every outstanding HTTP polling filter, keyed by
+// its hex-encoded id, and expires any filter idle for longer than
+// filterTTL via a background sweeper, the same way geth's filter API does.
+type filterRegistry struct {
+	mu      sync.Mutex
+	filters map[string]*filter
+}
+
+func newFilterRegistry() *filterRegistry {
+	r := &filterRegistry{filters: make(map[string]*filter)}
+	go r.sweepLoop()
+	return r
+}
+
+func (r *filterRegistry) sweepLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		r.sweep()
+	}
+}
+
+func (r *filterRegistry) sweep() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for id, f := range r.filters {
+		f.mu.Lock()
+		idle := now.Sub(f.lastPolled) > filterTTL
+		f.mu.Unlock()
+		if idle {
+			delete(r.filters, id)
+		}
+	}
+}
+
+func newFilterID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "0x" + hex.EncodeToString(b), nil
+}
+
+func (r *filterRegistry) create(f *filter) (string, error) {
+	id, err := newFilterID()
+	if err != nil {
+		return "", err
+	}
+	f.lastPolled = time.Now()
+	r.mu.Lock()
+	r.filters[id] = f
+	r.mu.Unlock()
+	return id, nil
+}
+
+func (r *filterRegistry) uninstall(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.filters[id]
+	delete(r.filters, id)
+	return ok
+}
+
+// get returns the filter for id and refreshes its idle timer, matching
+// the TTL semantics of a normal poll.
+func (r *filterRegistry) get(id string) (*filter, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.filters[id]
+	if ok {
+		f.mu.Lock()
+		f.lastPolled = time.Now()
+		f.mu.Unlock()
+	}
+	return f, ok
+}
+
+// parseFilterCriteriaParam decodes the single logFilterCriteria argument
+// to eth_newFilter.
+func parseFilterCriteriaParam(params []byte) (*logFilterCriteria, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(params, &raw); err != nil || len(raw) == 0 {
+		return nil, fmt.Errorf("eth_newFilter requires a filter object argument")
+	}
+	criteria := &logFilterCriteria{}
+	if err := json.Unmarshal(raw[0], criteria); err != nil {
+		return nil, err
+	}
+	return criteria, nil
+}
+
+// parseFilterIDParam decodes the single filter id argument shared by
+// eth_getFilterChanges, eth_getFilterLogs, and eth_uninstallFilter.
+func parseFilterIDParam(params []byte) (string, error) {
+	var raw []string
+	if err := json.Unmarshal(params, &raw); err != nil || len(raw) == 0 {
+		return "", fmt.Errorf("filter methods require a filter id argument")
+	}
+	return raw[0], nil
+}
+
+func (s *server) dispatchNewFilter(requestItem *jsonrpcMessage) *jsonrpcMessage {
+	criteria, err := parseFilterCriteriaParam(requestItem.Params)
+	if err != nil {
+		return requestItem.errorResponse(err)
+	}
+	id, err := s.filters.create(&filter{
+		kind:      logFilterKind,
+		criteria:  criteria,
+		logCursor: s.sim.VisibleLogCount(s.readyDepositCount()),
+	})
+	if err != nil {
+		return requestItem.errorResponse(err)
+	}
+	return requestItem.response(id)
+}
+
+func (s *server) dispatchNewBlockFilter(requestItem *jsonrpcMessage) *jsonrpcMessage {
+	id, err := s.filters.create(&filter{
+		kind:        blockFilterKind,
+		blockCursor: s.sim.Head().Number.Uint64(),
+	})
+	if err != nil {
+		return requestItem.errorResponse(err)
+	}
+	return requestItem.response(id)
+}
+
+func (s *server) dispatchGetFilterChanges(requestItem *jsonrpcMessage) *jsonrpcMessage {
+	id, err := parseFilterIDParam(requestItem.Params)
+	if err != nil {
+		return requestItem.errorResponse(err)
+	}
+	f, ok := s.filters.get(id)
+	if !ok {
+		return requestItem.errorResponse(fmt.Errorf("filter not found"))
+	}
+	if f.kind == blockFilterKind {
+		f.mu.Lock()
+		hashes := s.sim.HashesSince(f.blockCursor)
+		f.blockCursor = s.sim.Head().Number.Uint64()
+		f.mu.Unlock()
+		return requestItem.response(hashes)
+	}
+	return requestItem.response(s.matchingLogsSince(f))
+}
+
+func (s *server) dispatchGetFilterLogs(requestItem *jsonrpcMessage) *jsonrpcMessage {
+	id, err := parseFilterIDParam(requestItem.Params)
+	if err != nil {
+		return requestItem.errorResponse(err)
+	}
+	f, ok := s.filters.get(id)
+	if !ok {
+		return requestItem.errorResponse(fmt.Errorf("filter not found"))
+	}
+	if f.kind == blockFilterKind {
+		return requestItem.errorResponse(fmt.Errorf("eth_getFilterLogs does not support block filters"))
+	}
+	s.depositsLock.Lock()
+	visible := s.sim.VisibleLogCount(s.numDepositsReadyToSend)
+	logs := s.eth1Logs[:visible]
+	s.depositsLock.Unlock()
+	matched := make([]interface{}, 0, len(logs))
+	for _, l := range logs {
+		if f.criteria.matches(l) {
+			matched = append(matched, l)
+		}
+	}
+	return requestItem.response(matched)
+}
+
+func (s *server) dispatchUninstallFilter(requestItem *jsonrpcMessage) *jsonrpcMessage {
+	id, err := parseFilterIDParam(requestItem.Params)
+	if err != nil {
+		return requestItem.errorResponse(err)
+	}
+	return requestItem.response(s.filters.uninstall(id))
+}
+
+// matchingLogsSince returns every log at or after f.logCursor that
+// matches f.criteria and advances the cursor past them, used by
+// eth_getFilterChanges so a log is only ever delivered once. f.mu keeps
+// the cursor read-modify-write atomic against a second concurrent poll
+// of the same filter id.
+func (s *server) matchingLogsSince(f *filter) []interface{} {
+	s.depositsLock.Lock()
+	visible := s.sim.VisibleLogCount(s.numDepositsReadyToSend)
+	logs := s.eth1Logs[:visible]
+	s.depositsLock.Unlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	matched := make([]interface{}, 0)
+	for ; f.logCursor < len(logs); f.logCursor++ {
+		l := logs[f.logCursor]
+		if f.criteria.matches(l) {
+			matched = append(matched, l)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestMatchingLogsSinceAdvancesCursor(t *testing.T) {
+	srv := newTestServer()
+	srv.eth1Logs = []types.Log{
+		{Address: common.HexToAddress("0x1")},
+		{Address: common.HexToAddress("0x2")},
+		{Address: common.HexToAddress("0x3")},
+	}
+	srv.numDepositsReadyToSend = 3
+	for i := 0; i < 10; i++ {
+		srv.sim.Advance()
+	}
+
+	f := &filter{kind: logFilterKind, criteria: &logFilterCriteria{}}
+
+	first := srv.matchingLogsSince(f)
+	if len(first) != 3 {
+		t.Fatalf("first poll: got %d logs, want 3", len(first))
+	}
+	if f.logCursor != 3 {
+		t.Fatalf("logCursor after first poll = %d, want 3", f.logCursor)
+	}
+
+	second := srv.matchingLogsSince(f)
+	if len(second) != 0 {
+		t.Fatalf("second poll should not redeliver already-seen logs, got %d", len(second))
+	}
+}
+
+func TestMatchingLogsSinceGatedByFinality(t *testing.T) {
+	srv := newTestServer()
+	srv.eth1Logs = []types.Log{{Address: common.HexToAddress("0x1")}}
+	srv.numDepositsReadyToSend = 1
+	// No Advance() calls: head is still genesis, so VisibleLogCount is 0
+	// and the ready deposit log must not be delivered yet.
+
+	f := &filter{kind: logFilterKind, criteria: &logFilterCriteria{}}
+	got := srv.matchingLogsSince(f)
+	if len(got) != 0 {
+		t.Fatalf("got %d logs before the chain advanced past genesis, want 0", len(got))
+	}
+}
+
+func TestFilterRegistrySweepExpiresIdleFilters(t *testing.T) {
+	r := &filterRegistry{filters: make(map[string]*filter)}
+	fresh := &filter{lastPolled: time.Now()}
+	stale := &filter{lastPolled: time.Now().Add(-2 * filterTTL)}
+	r.filters["fresh"] = fresh
+	r.filters["stale"] = stale
+
+	r.sweep()
+
+	if _, ok := r.filters["fresh"]; !ok {
+		t.Errorf("sweep removed a recently-polled filter")
+	}
+	if _, ok := r.filters["stale"]; ok {
+		t.Errorf("sweep did not remove a filter idle past filterTTL")
+	}
+}
+
+func TestFilterRegistryGetRefreshesLastPolled(t *testing.T) {
+	r := &filterRegistry{filters: make(map[string]*filter)}
+	id, err := r.create(&filter{})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	// create stamps lastPolled itself, so make it stale afterwards.
+	r.filters[id].lastPolled = time.Now().Add(-2 * filterTTL)
+
+	f, ok := r.get(id)
+	if !ok {
+		t.Fatalf("get did not find the created filter")
+	}
+	if time.Since(f.lastPolled) > time.Second {
+		t.Errorf("get did not refresh lastPolled")
+	}
+}
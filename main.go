@@ -14,11 +14,14 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/prysmaticlabs/eth1-mock-rpc/eth1"
+	"github.com/prysmaticlabs/eth1-mock-rpc/eth1/sim"
 	"github.com/sirupsen/logrus"
 	prefixed "github.com/x-cray/logrus-prefixed-formatter"
 	"golang.org/x/net/websocket"
@@ -36,6 +39,10 @@ var (
 	httpPort              = flag.String("http-port", "7777", "Port on which to serve http listeners")
 	invalidateCache       = flag.Bool("invalidate-cache", false, "Recalculate deposits into a cache from a keystore")
 	numGenesisDeposits    = flag.Int("genesis-deposits", 0, "Number of deposits to read from the keystore to trigger the genesis event")
+	blockTime             = flag.Duration("block-time", 10*time.Second, "Interval between simulated blocks")
+	reorgDepth            = flag.Int("reorg-depth", 0, "Number of most recent blocks to rewrite on a simulated reorg (0 disables reorgs)")
+	reorgProbability      = flag.Float64("reorg-probability", 0, "Probability in [0,1] of a reorg of --reorg-depth blocks occurring on any given block")
+	finalityDelay         = flag.Uint64("finality-delay", 0, "Number of blocks that must build on top of a deposit log's block before it is visible to eth_getLogs")
 	verbosity             = flag.String("verbosity", "info", "Logging verbosity (debug, info=default, warn, error, fatal, panic)")
 	log                   = logrus.WithField("prefix", "main")
 	persistedDepositsJSON = "deposits.json"
@@ -47,13 +54,25 @@ type server struct {
 	deposits               []*eth1.DepositData
 	eth1Logs               []types.Log
 	genesisTime            uint64
+	sim                    *sim.ChainSimulator
+	subscriberCount        int64 // atomic
+	filters                *filterRegistry
+	replay                 *replayStore
+	recorder               *recorder
+	upstreamURL            string
+	wsHandlersMu           sync.Mutex
+	wsHandlers             map[*websocketHandler]struct{}
 }
 
 type websocketHandler struct {
-	blockNum      uint64
-	close         chan bool
-	readOperation chan []*jsonrpcMessage // Channel for read messages from the codec.
-	readErr       chan error
+	srv             *server
+	remote          string
+	codec           ServerCodec
+	close           chan bool
+	readOperation   chan []*jsonrpcMessage // Channel for read messages from the codec.
+	readErr         chan error
+	subscriptionsMu sync.Mutex
+	subscriptions   map[rpc.ID]*subscription
 }
 
 func main() {
@@ -120,109 +139,285 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	adminListener, err := net.Listen("tcp", fmt.Sprintf("localhost:%s", *adminPort))
+	if err != nil {
+		log.Fatal(err)
+	}
+	metricsListener, err := net.Listen("tcp", fmt.Sprintf("localhost:%s", *metricsPort))
+	if err != nil {
+		log.Fatal(err)
+	}
 	logs, err := eth1.DepositEventLogs(allDeposits)
 	if err != nil {
 		log.Fatal(err)
 	}
+	genesisTime := uint64(time.Now().Add(10 * time.Second).Unix())
 	srv := &server{
 		numDepositsReadyToSend: *numGenesisDeposits,
 		deposits:               allDeposits,
 		eth1Logs:               logs,
-		genesisTime:            uint64(time.Now().Add(10 * time.Second).Unix()),
+		genesisTime:            genesisTime,
+		sim: sim.New(sim.Config{
+			BlockTime:        *blockTime,
+			ReorgDepth:       *reorgDepth,
+			ReorgProbability: *reorgProbability,
+			FinalityDelay:    *finalityDelay,
+		}, genesisTime),
+		filters:     newFilterRegistry(),
+		upstreamURL: *upstream,
+		wsHandlers:  make(map[*websocketHandler]struct{}),
+	}
+	if *replayFile != "" {
+		store, err := loadReplayStore(*replayFile)
+		if err != nil {
+			log.Fatalf("Could not load --replay transcript from %s: %v", *replayFile, err)
+		}
+		srv.replay = store
+		log.Infof("Replaying recorded responses from %s", *replayFile)
 	}
+	if *recordFile != "" {
+		if *upstream == "" {
+			log.Fatal("--record requires --upstream to be set")
+		}
+		rec, err := newRecorder(*recordFile)
+		if err != nil {
+			log.Fatalf("Could not open --record transcript at %s: %v", *recordFile, err)
+		}
+		srv.recorder = rec
+		log.Infof("Recording proxied responses from %s to %s", *upstream, *recordFile)
+	}
+	srv.updateDepositGauges()
 	log.Println("Starting HTTP listener on port :7777")
 	go http.Serve(httpListener, srv)
 
 	log.Println("Starting WebSocket listener on port :7778")
 	wsSrv := &http.Server{Handler: srv.ServeWebsocket()}
 	go wsSrv.Serve(wsListener)
+	go srv.runChainSimulator()
+
+	log.Printf("Starting admin listener on port :%s", *adminPort)
+	go http.Serve(adminListener, srv.adminMux())
+
+	log.Printf("Starting Prometheus metrics listener on port :%s", *metricsPort)
+	startMetricsServer(metricsListener)
 
 	go srv.listenForDepositTrigger()
 
 	select {}
 }
 
+// requestFields builds the consistent set of logrus fields used across
+// every RPC codepath, so operators can grep one request across both logs
+// and metrics regardless of which transport it came in on.
+func requestFields(method, id, transport, remote string) logrus.Fields {
+	return logrus.Fields{
+		"method":    method,
+		"id":        id,
+		"transport": transport,
+		"remote":    remote,
+	}
+}
+
+// ServeHTTP dispatches every message in the request, in order, whether it
+// arrived as a single JSON-RPC object or a batch array, and writes back a
+// response shaped the same way the request was: a single object for a
+// single request, or a JSON array lined up with the batch. Notification
+// requests (no id) are executed but, per the JSON-RPC 2.0 spec, never
+// produce an entry in the response.
 func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
 	body := io.LimitReader(r.Body, maxRequestContentLength)
 	conn := &httpServerConn{Reader: body, Writer: w, r: r}
 	codec := NewJSONCodec(conn)
 	defer codec.Close()
-	msgs, _, err := codec.Read()
+	msgs, isBatch, err := codec.Read()
 	if err != nil {
 		log.WithError(err).Error("Could not read data from request")
+		if werr := codec.Write(ctx, errorMessage(err)); werr != nil {
+			log.Error(werr)
+		}
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	requestItem := msgs[0]
-	if !requestItem.isCall() {
-		log.WithField("messageType", requestItem.Method).Error("Can only serve RPC call types via HTTP")
-		w.WriteHeader(http.StatusInternalServerError)
+
+	responses := make([]*jsonrpcMessage, 0, len(msgs))
+	for _, requestItem := range msgs {
+		fields := requestFields(requestItem.Method, string(requestItem.ID), "http", r.RemoteAddr)
+		if !requestItem.isCall() {
+			log.WithFields(fields).Debug("Received HTTP-RPC notification")
+			s.dispatchHTTP(requestItem)
+			continue
+		}
+		log.WithFields(fields).Debug("Received HTTP-RPC request")
+		log.Debugf("%v", requestItem)
+
+		start := time.Now()
+		rpcRequestsTotal.WithLabelValues(requestItem.Method, "http").Inc()
+		response := s.dispatchHTTP(requestItem)
+		rpcRequestDuration.WithLabelValues(requestItem.Method).Observe(time.Since(start).Seconds())
+		responses = append(responses, response)
+	}
+
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusNoContent)
 		return
 	}
-	log.WithField("method", requestItem.Method).Debug("Received HTTP-RPC request")
-	log.Debugf("%v", requestItem)
+	var writeErr error
+	if isBatch {
+		writeErr = codec.Write(ctx, responses)
+	} else {
+		writeErr = codec.Write(ctx, responses[0])
+	}
+	if writeErr != nil {
+		log.Error(writeErr)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// readyDepositCount returns numDepositsReadyToSend under depositsLock. It
+// is the one place every RPC surface should read the field through,
+// instead of touching s.numDepositsReadyToSend directly and racing with
+// listenForDepositTrigger/triggerDeposits.
+func (s *server) readyDepositCount() int {
+	s.depositsLock.Lock()
+	defer s.depositsLock.Unlock()
+	return s.numDepositsReadyToSend
+}
+
+// dispatchHTTP executes a single JSON-RPC call and returns the message to
+// send back: either the method's result or a JSON-RPC error envelope
+// (code, message, data) built with defaultErrorCode for unknown methods
+// and malformed params.
+func (s *server) dispatchHTTP(requestItem *jsonrpcMessage) *jsonrpcMessage {
+	if s.replay != nil {
+		if result, ok := s.replay.lookup(requestItem.Method, requestItem.Params); ok {
+			return requestItem.rawResponse(result)
+		}
+	}
+	if s.recorder != nil && s.upstreamURL != "" {
+		result, err := proxyToUpstream(s.upstreamURL, requestItem)
+		if err != nil {
+			log.WithError(err).Error("Could not proxy request to upstream")
+			return requestItem.errorResponse(err)
+		}
+		s.recorder.record(requestItem.Method, requestItem.Params, result)
+		return requestItem.rawResponse(result)
+	}
 
-	stringRep := requestItem.String()
 	switch requestItem.Method {
 	case "eth_getBlockByNumber":
-		block := eth1.BlockHeaderByNumber()
-		response := requestItem.response(block)
-		if err := codec.Write(ctx, response); err != nil {
-			log.Error(err)
-			w.WriteHeader(http.StatusInternalServerError)
+		num, latest, err := parseBlockNumberParam(requestItem.Params)
+		if err != nil {
+			return requestItem.errorResponse(err)
+		}
+		header := s.sim.Head()
+		if !latest {
+			header = s.sim.BlockByNumber(num)
 		}
+		return requestItem.response(header)
 	case "eth_getBlockByHash":
-		block := eth1.BlockHeaderByHash(s.genesisTime)
-		response := requestItem.response(block)
-		if err := codec.Write(ctx, response); err != nil {
-			log.Error(err)
-			w.WriteHeader(http.StatusInternalServerError)
+		hash, err := parseBlockHashParam(requestItem.Params)
+		if err != nil {
+			return requestItem.errorResponse(err)
 		}
+		return requestItem.response(s.sim.BlockByHash(hash))
 	case "eth_getLogs":
-		response := requestItem.response(s.eth1Logs[:s.numDepositsReadyToSend])
-		if err := codec.Write(ctx, response); err != nil {
-			log.Error(err)
-			w.WriteHeader(http.StatusInternalServerError)
-		}
+		visible := s.sim.VisibleLogCount(s.readyDepositCount())
+		return requestItem.response(s.eth1Logs[:visible])
 	case "eth_call":
-		if strings.Contains(stringRep, eth1.DepositMethodID()) {
-			count := eth1.DepositCount(s.deposits[:s.numDepositsReadyToSend])
-			depCount, err := eth1.PackDepositCount(count[:])
-			if err != nil {
-				log.WithError(err).Error("Could not respond to HTTP request")
-				w.WriteHeader(http.StatusInternalServerError)
-			}
-			response := requestItem.response(fmt.Sprintf("%#x", depCount))
-			if err := codec.Write(ctx, response); err != nil {
-				log.Error(err)
-				w.WriteHeader(http.StatusInternalServerError)
-			}
-			return
+		return s.dispatchEthCall(requestItem)
+	case "eth_newFilter":
+		return s.dispatchNewFilter(requestItem)
+	case "eth_newBlockFilter":
+		return s.dispatchNewBlockFilter(requestItem)
+	case "eth_getFilterChanges":
+		return s.dispatchGetFilterChanges(requestItem)
+	case "eth_getFilterLogs":
+		return s.dispatchGetFilterLogs(requestItem)
+	case "eth_uninstallFilter":
+		return s.dispatchUninstallFilter(requestItem)
+	default:
+		return requestItem.errorResponse(fmt.Errorf("the method %s does not exist/is not available", requestItem.Method))
+	}
+}
+
+// dispatchEthCall answers the two deposit-contract selectors the mock
+// understands; anything else is reported as an RPC error rather than
+// silently dropped.
+func (s *server) dispatchEthCall(requestItem *jsonrpcMessage) *jsonrpcMessage {
+	stringRep := requestItem.String()
+	ready := s.readyDepositCount()
+	if strings.Contains(stringRep, eth1.DepositMethodID()) {
+		count := eth1.DepositCount(s.deposits[:ready])
+		depCount, err := eth1.PackDepositCount(count[:])
+		if err != nil {
+			log.WithError(err).Error("Could not respond to eth_call request")
+			return requestItem.errorResponse(err)
 		}
-		if strings.Contains(stringRep, eth1.DepositLogsID()) {
-			root, err := eth1.DepositRoot(s.deposits[:s.numDepositsReadyToSend])
-			if err != nil {
-				log.WithError(err).Error("Could not respond to HTTP request")
-				w.WriteHeader(http.StatusInternalServerError)
-				return
-			}
-			response := requestItem.response(fmt.Sprintf("%#x", root))
-			if err := codec.Write(ctx, response); err != nil {
-				log.Error(err)
-				w.WriteHeader(http.StatusInternalServerError)
-			}
-			return
+		return requestItem.response(fmt.Sprintf("%#x", depCount))
+	}
+	if strings.Contains(stringRep, eth1.DepositLogsID()) {
+		root, err := eth1.DepositRoot(s.deposits[:ready])
+		if err != nil {
+			log.WithError(err).Error("Could not respond to eth_call request")
+			return requestItem.errorResponse(err)
 		}
-		s.defaultResponse(w)
+		return requestItem.response(fmt.Sprintf("%#x", root))
+	}
+	return requestItem.errorResponse(fmt.Errorf("eth_call to unknown selector is not supported by this mock"))
+}
+
+// parseBlockNumberParam decodes the first argument to eth_getBlockByNumber,
+// which is either a hex block number tag or one of the reserved aliases
+// "latest"/"pending"/"earliest".
+func parseBlockNumberParam(params []byte) (num uint64, latest bool, err error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(params, &raw); err != nil || len(raw) == 0 {
+		return 0, false, fmt.Errorf("eth_getBlockByNumber requires a block number argument")
+	}
+	var tag string
+	if err := json.Unmarshal(raw[0], &tag); err != nil {
+		return 0, false, err
+	}
+	switch tag {
+	case "latest", "pending":
+		return 0, true, nil
+	case "earliest":
+		return 0, false, nil
 	default:
-		s.defaultResponse(w)
+		n, err := strconv.ParseUint(strings.TrimPrefix(tag, "0x"), 16, 64)
+		return n, false, err
+	}
+}
+
+// parseBlockHashParam decodes the first argument to eth_getBlockByHash.
+func parseBlockHashParam(params []byte) (common.Hash, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(params, &raw); err != nil || len(raw) == 0 {
+		return common.Hash{}, fmt.Errorf("eth_getBlockByHash requires a block hash argument")
+	}
+	var hash string
+	if err := json.Unmarshal(raw[0], &hash); err != nil {
+		return common.Hash{}, err
 	}
+	return common.HexToHash(hash), nil
 }
 
-func (s *server) defaultResponse(w http.ResponseWriter) {
-	log.Error("Could not respond to HTTP request")
-	w.WriteHeader(http.StatusBadRequest)
+// errorResponse builds a JSON-RPC error envelope for this request,
+// preserving its version and id so callers (including batch callers) can
+// still match the error back to the call that produced it.
+func (msg *jsonrpcMessage) errorResponse(err error) *jsonrpcMessage {
+	resp := errorMessage(err)
+	resp.Version = msg.Version
+	resp.ID = msg.ID
+	return resp
+}
+
+// rawResponse builds a response whose Result is already-encoded JSON, used
+// for replayed and proxied responses where byte-for-byte fidelity with
+// what a real node sent matters more than re-marshaling convenience.
+func (msg *jsonrpcMessage) rawResponse(result json.RawMessage) *jsonrpcMessage {
+	return &jsonrpcMessage{Version: msg.Version, ID: msg.ID, Result: result}
 }
 
 func (s *server) ServeWebsocket() http.Handler {
@@ -230,24 +425,75 @@ func (s *server) ServeWebsocket() http.Handler {
 		Handler: func(conn *websocket.Conn) {
 			codec := newWebsocketCodec(conn)
 			wsHandler := &websocketHandler{
-				blockNum:      0,
+				srv:           s,
+				remote:        conn.Request().RemoteAddr,
+				codec:         codec,
 				close:         make(chan bool),
 				readOperation: make(chan []*jsonrpcMessage),
 				readErr:       make(chan error),
+				subscriptions: make(map[rpc.ID]*subscription),
 			}
+			s.registerWebsocket(wsHandler)
 
 			defer codec.Close()
 			// Listen to read events from the codec and dispatch events or errors accordingly.
 			go wsHandler.websocketReadLoop(codec)
 			go wsHandler.dispatchWebsocketEventLoop(codec)
 			<-codec.Closed()
+			s.unregisterWebsocket(wsHandler)
+
+			wsHandler.subscriptionsMu.Lock()
+			atomic.AddInt64(&s.subscriberCount, -int64(len(wsHandler.subscriptions)))
+			activeSubscriptions.Sub(float64(len(wsHandler.subscriptions)))
+			wsHandler.subscriptionsMu.Unlock()
 		},
 	}
 }
 
+// registerWebsocket adds w to the set of connections runChainSimulator
+// pushes newHeads/logs notifications to.
+func (s *server) registerWebsocket(w *websocketHandler) {
+	s.wsHandlersMu.Lock()
+	s.wsHandlers[w] = struct{}{}
+	s.wsHandlersMu.Unlock()
+}
+
+func (s *server) unregisterWebsocket(w *websocketHandler) {
+	s.wsHandlersMu.Lock()
+	delete(s.wsHandlers, w)
+	s.wsHandlersMu.Unlock()
+}
+
+// runChainSimulator is the single goroutine that advances the shared
+// ChainSimulator on every --block-time tick and pushes the result to
+// every currently-connected websocket subscriber. It is owned by server,
+// not by any one connection, so the chain keeps advancing with zero
+// websockets open and every connection sees the same ticks.
+func (s *server) runChainSimulator() {
+	tick := time.NewTicker(s.sim.Config().BlockTime)
+	defer tick.Stop()
+	for range tick.C {
+		head, reorged := s.sim.Advance()
+		if reorged {
+			reorgsTotal.Inc()
+		}
+		currentBlockNumGauge.Set(float64(head.Number.Uint64()))
+
+		s.wsHandlersMu.Lock()
+		handlers := make([]*websocketHandler, 0, len(s.wsHandlers))
+		for w := range s.wsHandlers {
+			handlers = append(handlers, w)
+		}
+		s.wsHandlersMu.Unlock()
+
+		for _, w := range handlers {
+			w.notifyNewHeads(w.codec, head)
+			w.notifyLogs(w.codec)
+		}
+	}
+}
+
 func (w *websocketHandler) dispatchWebsocketEventLoop(codec ServerCodec) {
-	tick := time.NewTicker(time.Second * 10)
-	var latestSubID rpc.ID
 	for {
 		select {
 		case <-w.close:
@@ -255,37 +501,137 @@ func (w *websocketHandler) dispatchWebsocketEventLoop(codec ServerCodec) {
 		case err := <-w.readErr:
 			log.WithError(err).Error("Could not read data from request")
 			return
-		case <-tick.C:
-			head := eth1.LatestChainHead(w.blockNum)
-			data, _ := json.Marshal(head)
-			params, _ := json.Marshal(&subscriptionResult{ID: string(latestSubID), Result: data})
-			ctx := context.Background()
-			item := &jsonrpcMessage{
-				Version: "2.0",
-				Method:  "eth_subscription",
-				Params:  params,
-			}
-			if err := codec.Write(ctx, item); err != nil {
-				log.Error(err)
-				continue
-			}
-			w.blockNum++
 		case msgs := <-w.readOperation:
-			sub := &rpc.Subscription{ID: rpc.NewID()}
-			item := &jsonrpcMessage{
-				Version: msgs[0].Version,
-				ID:      msgs[0].ID,
+			for _, msg := range msgs {
+				w.handleMessage(codec, msg)
 			}
-			latestSubID = sub.ID
-			newItem := item.response(sub)
-			if err := codec.Write(context.Background(), newItem); err != nil {
-				log.Error(err)
-				continue
+		}
+	}
+}
+
+// handleMessage dispatches a single websocket RPC message to the
+// subscription management handler for its method. Anything other than
+// eth_subscribe/eth_unsubscribe is not meaningful over this codepath.
+func (w *websocketHandler) handleMessage(codec ServerCodec, msg *jsonrpcMessage) {
+	rpcRequestsTotal.WithLabelValues(msg.Method, "ws").Inc()
+	fields := requestFields(msg.Method, string(msg.ID), "ws", w.remote)
+	switch msg.Method {
+	case "eth_subscribe":
+		w.handleSubscribe(codec, msg)
+	case "eth_unsubscribe":
+		w.handleUnsubscribe(codec, msg)
+	default:
+		log.WithFields(fields).Warn("Unsupported websocket RPC method")
+	}
+}
+
+func (w *websocketHandler) handleSubscribe(codec ServerCodec, msg *jsonrpcMessage) {
+	subType, filter, err := parseSubscribeParams(msg.Params)
+	if err != nil {
+		if werr := codec.Write(context.Background(), errorMessage(err)); werr != nil {
+			log.Error(werr)
+		}
+		return
+	}
+	sub := &subscription{
+		id:           rpc.NewID(),
+		subType:      subType,
+		filter:       filter,
+		lastLogIndex: w.srv.readyDepositCount(),
+	}
+	w.subscriptionsMu.Lock()
+	w.subscriptions[sub.id] = sub
+	w.subscriptionsMu.Unlock()
+	atomic.AddInt64(&w.srv.subscriberCount, 1)
+	activeSubscriptions.Inc()
+
+	item := &jsonrpcMessage{Version: msg.Version, ID: msg.ID}
+	if err := codec.Write(context.Background(), item.response(sub.id)); err != nil {
+		log.Error(err)
+	}
+}
+
+func (w *websocketHandler) handleUnsubscribe(codec ServerCodec, msg *jsonrpcMessage) {
+	id, err := parseUnsubscribeParams(msg.Params)
+	if err != nil {
+		if werr := codec.Write(context.Background(), errorMessage(err)); werr != nil {
+			log.Error(werr)
+		}
+		return
+	}
+	w.subscriptionsMu.Lock()
+	_, ok := w.subscriptions[id]
+	delete(w.subscriptions, id)
+	w.subscriptionsMu.Unlock()
+	if ok {
+		atomic.AddInt64(&w.srv.subscriberCount, -1)
+		activeSubscriptions.Dec()
+	}
+
+	item := &jsonrpcMessage{Version: msg.Version, ID: msg.ID}
+	if err := codec.Write(context.Background(), item.response(ok)); err != nil {
+		log.Error(err)
+	}
+}
+
+// notifyNewHeads pushes head to every subscriber of the "newHeads" type.
+func (w *websocketHandler) notifyNewHeads(codec ServerCodec, head interface{}) {
+	w.subscriptionsMu.Lock()
+	defer w.subscriptionsMu.Unlock()
+	for _, sub := range w.subscriptions {
+		if sub.subType != subscriptionNewHeads {
+			continue
+		}
+		w.publish(codec, sub.id, head)
+	}
+}
+
+// notifyLogs pushes every deposit log that has become visible (per
+// sim.VisibleLogCount, the same finality gate eth_getLogs and the filter
+// polling handlers use) since a "logs" subscriber last caught up, filtered
+// by that subscriber's address and topics criteria.
+func (w *websocketHandler) notifyLogs(codec ServerCodec) {
+	w.srv.depositsLock.Lock()
+	visible := w.srv.sim.VisibleLogCount(w.srv.numDepositsReadyToSend)
+	logs := w.srv.eth1Logs[:visible]
+	w.srv.depositsLock.Unlock()
+
+	w.subscriptionsMu.Lock()
+	defer w.subscriptionsMu.Unlock()
+	for _, sub := range w.subscriptions {
+		if sub.subType != subscriptionLogs {
+			continue
+		}
+		for ; sub.lastLogIndex < len(logs); sub.lastLogIndex++ {
+			l := logs[sub.lastLogIndex]
+			if sub.filter.matches(l) {
+				w.publish(codec, sub.id, l)
 			}
 		}
 	}
 }
 
+func (w *websocketHandler) publish(codec ServerCodec, id rpc.ID, result interface{}) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	params, err := json.Marshal(&subscriptionResult{ID: string(id), Result: data})
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	item := &jsonrpcMessage{
+		Version: "2.0",
+		Method:  "eth_subscription",
+		Params:  params,
+	}
+	if err := codec.Write(context.Background(), item); err != nil {
+		log.Error(err)
+	}
+}
+
 func (w *websocketHandler) websocketReadLoop(codec ServerCodec) {
 	for {
 		select {
@@ -311,10 +657,9 @@ func (w *websocketHandler) websocketReadLoop(codec ServerCodec) {
 func (s *server) listenForDepositTrigger() {
 	reader := bufio.NewReader(os.Stdin)
 	for {
-		maxAllowed := len(s.deposits) - s.numDepositsReadyToSend
 		log.Printf(
 			"Enter the number of new eth2 deposits to trigger (max allowed %d): ",
-			maxAllowed,
+			len(s.deposits)-s.readyDepositCount(),
 		)
 		fmt.Print(">> ")
 		line, _, err := reader.ReadLine()
@@ -325,15 +670,14 @@ func (s *server) listenForDepositTrigger() {
 		num, err := strconv.Atoi(string(line))
 		if err != nil {
 			log.Error(err)
+			continue
 		}
-		if num > maxAllowed {
-			log.Errorf(
-				"You have already sent %d/%d available deposits in keystore, cannot submit more",
-				len(s.deposits),
-				s.numDepositsReadyToSend,
-			)
+		// triggerDeposits does the whole check-and-increment under
+		// depositsLock, so this stays race-free against a concurrent
+		// POST /deposits/trigger or an active /deposits/schedule drip.
+		if err := s.triggerDeposits(num); err != nil {
+			log.Error(err)
 			continue
 		}
-		s.numDepositsReadyToSend += num
 	}
 }
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/prysmaticlabs/eth1-mock-rpc/eth1/sim"
+)
+
+func newTestServer() *server {
+	return &server{
+		eth1Logs: []types.Log{},
+		sim:      sim.New(sim.Config{BlockTime: time.Second}, uint64(time.Now().Unix())),
+	}
+}
+
+func TestServeHTTP_Batch(t *testing.T) {
+	tests := []struct {
+		name          string
+		requests      []map[string]interface{}
+		wantResponses int
+		wantErrorAt   map[int]bool
+	}{
+		{
+			name: "single known method",
+			requests: []map[string]interface{}{
+				{"jsonrpc": "2.0", "id": 1, "method": "eth_getLogs", "params": []interface{}{}},
+			},
+			wantResponses: 1,
+		},
+		{
+			name: "batch of known and unknown methods",
+			requests: []map[string]interface{}{
+				{"jsonrpc": "2.0", "id": 1, "method": "eth_getLogs", "params": []interface{}{}},
+				{"jsonrpc": "2.0", "id": 2, "method": "does_not_exist", "params": []interface{}{}},
+			},
+			wantResponses: 2,
+			wantErrorAt:   map[int]bool{1: true},
+		},
+		{
+			name: "batch with a notification",
+			requests: []map[string]interface{}{
+				{"jsonrpc": "2.0", "id": 1, "method": "eth_getLogs", "params": []interface{}{}},
+				{"jsonrpc": "2.0", "method": "eth_getLogs", "params": []interface{}{}},
+			},
+			wantResponses: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer()
+			ts := httptest.NewServer(srv)
+			defer ts.Close()
+
+			body, err := json.Marshal(tt.requests)
+			if err != nil {
+				t.Fatalf("could not marshal request: %v", err)
+			}
+			resp, err := http.Post(ts.URL, "application/json", bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("could not POST request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			var got []map[string]interface{}
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("could not decode response: %v", err)
+			}
+			if len(got) != tt.wantResponses {
+				t.Fatalf("got %d responses, want %d", len(got), tt.wantResponses)
+			}
+			for i, wantErr := range tt.wantErrorAt {
+				_, hasError := got[i]["error"]
+				if hasError != wantErr {
+					t.Errorf("response %d: got error=%v, want %v (%v)", i, hasError, wantErr, got[i])
+				}
+			}
+		})
+	}
+}
+
+// TestServeHTTP_SingleObject posts a bare (non-array) JSON-RPC request and
+// asserts ServeHTTP responds with a bare object too, exercising the
+// isBatch == false path that TestServeHTTP_Batch's "single known method"
+// case never reaches since json.Marshal of a []map[string]interface{}
+// always produces an array.
+func TestServeHTTP_SingleObject(t *testing.T) {
+	srv := newTestServer()
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	request := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_getLogs",
+		"params":  []interface{}{},
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		t.Fatalf("could not marshal request: %v", err)
+	}
+	resp, err := http.Post(ts.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("could not POST request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var got map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("could not decode response as a bare object: %v", err)
+	}
+	if _, hasError := got["error"]; hasError {
+		t.Errorf("got error response, want success: %v", got)
+	}
+	if _, hasResult := got["result"]; !hasResult {
+		t.Errorf("response missing result field: %v", got)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"net"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var metricsPort = flag.String("metrics-port", "2112", "Port on which to serve Prometheus metrics")
+
+var (
+	rpcRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "eth1_mock_rpc_requests_total",
+		Help: "Total number of RPC calls received, broken down by method and transport.",
+	}, []string{"method", "transport"})
+
+	rpcRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "eth1_mock_rpc_request_duration_seconds",
+		Help:    "Latency of HTTP RPC requests in seconds.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+
+	activeSubscriptions = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "eth1_mock_rpc_active_subscriptions",
+		Help: "Number of currently active websocket eth_subscribe subscriptions.",
+	})
+
+	depositsReadyGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "eth1_mock_rpc_deposits_ready_to_send",
+		Help: "Number of deposits currently marked ready to send.",
+	})
+
+	depositsPendingGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "eth1_mock_rpc_deposits_pending",
+		Help: "Number of deposits loaded from the keystore but not yet triggered.",
+	})
+
+	reorgsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "eth1_mock_rpc_reorgs_total",
+		Help: "Total number of simulated chain reorgs.",
+	})
+
+	currentBlockNumGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "eth1_mock_rpc_block_number",
+		Help: "Current simulated chain head block number.",
+	})
+)
+
+// startMetricsServer serves the Prometheus scrape endpoint on listener.
+func startMetricsServer(listener net.Listener) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	go http.Serve(listener, mux)
+}
+
+// updateDepositGauges refreshes the ready/pending deposit gauges. Callers
+// must hold s.depositsLock.
+func (s *server) updateDepositGauges() {
+	depositsReadyGauge.Set(float64(s.numDepositsReadyToSend))
+	depositsPendingGauge.Set(float64(len(s.deposits) - s.numDepositsReadyToSend))
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// The subscription types understood by eth_subscribe, matching what
+// go-ethereum's ethclient and Prysm's powchain service issue over websocket.
+const (
+	subscriptionNewHeads               = "newHeads"
+	subscriptionLogs                   = "logs"
+	subscriptionNewPendingTransactions = "newPendingTransactions"
+	subscriptionSyncing                = "syncing"
+)
+
+// logFilterCriteria mirrors the filter object accepted as the second
+// argument to eth_subscribe("logs", ...) and by eth_newFilter: a nil
+// Address/Topics entry matches anything, a slice matches any of its
+// entries (OR), and each position in Topics is AND'ed against the next.
+type logFilterCriteria struct {
+	Address interface{}   `json:"address"`
+	Topics  []interface{} `json:"topics"`
+}
+
+// matches reports whether l satisfies the filter, following the same
+// address/topics semantics as eth_getLogs: nil = wildcard, array = OR,
+// and topic positions are AND'ed together.
+func (f *logFilterCriteria) matches(l types.Log) bool {
+	if f == nil {
+		return true
+	}
+	return matchesAddress(f.Address, l.Address) && matchesTopics(f.Topics, l.Topics)
+}
+
+func matchesAddress(filterAddr interface{}, logAddr common.Address) bool {
+	switch addr := filterAddr.(type) {
+	case nil:
+		return true
+	case string:
+		return common.HexToAddress(addr) == logAddr
+	case []interface{}:
+		if len(addr) == 0 {
+			return true
+		}
+		for _, a := range addr {
+			if s, ok := a.(string); ok && common.HexToAddress(s) == logAddr {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+func matchesTopics(filterTopics []interface{}, logTopics []common.Hash) bool {
+	if len(filterTopics) > len(logTopics) {
+		return false
+	}
+	for i, want := range filterTopics {
+		if want == nil {
+			continue
+		}
+		switch t := want.(type) {
+		case string:
+			if common.HexToHash(t) != logTopics[i] {
+				return false
+			}
+		case []interface{}:
+			if len(t) == 0 {
+				continue
+			}
+			matched := false
+			for _, opt := range t {
+				if s, ok := opt.(string); ok && common.HexToHash(s) == logTopics[i] {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// subscription tracks a single eth_subscribe registration for one
+// websocket connection. lastLogIndex is the offset into server.eth1Logs
+// already delivered to this subscriber, so a "logs" subscription only
+// ever sees entries that became ready to send after it was created.
+type subscription struct {
+	id           rpc.ID
+	subType      string
+	filter       *logFilterCriteria
+	lastLogIndex int
+}
+
+// parseSubscribeParams decodes the params array of an eth_subscribe
+// request: the subscription type name, followed by an optional filter
+// object that only "logs" subscriptions use.
+func parseSubscribeParams(params []byte) (string, *logFilterCriteria, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(params, &raw); err != nil {
+		return "", nil, err
+	}
+	if len(raw) == 0 {
+		return "", nil, fmt.Errorf("eth_subscribe requires at least one argument")
+	}
+	var subType string
+	if err := json.Unmarshal(raw[0], &subType); err != nil {
+		return "", nil, err
+	}
+	switch subType {
+	case subscriptionNewHeads, subscriptionNewPendingTransactions, subscriptionSyncing:
+		return subType, nil, nil
+	case subscriptionLogs:
+		var filter *logFilterCriteria
+		if len(raw) > 1 {
+			filter = &logFilterCriteria{}
+			if err := json.Unmarshal(raw[1], filter); err != nil {
+				return "", nil, err
+			}
+		}
+		return subType, filter, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported subscription type %q", subType)
+	}
+}
+
+// parseUnsubscribeParams decodes the single-element params array of an
+// eth_unsubscribe request into the subscription id it targets.
+func parseUnsubscribeParams(params []byte) (rpc.ID, error) {
+	var raw []string
+	if err := json.Unmarshal(params, &raw); err != nil || len(raw) == 0 {
+		return "", fmt.Errorf("eth_unsubscribe requires a subscription id")
+	}
+	return rpc.ID(raw[0]), nil
+}
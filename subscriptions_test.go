@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestMatchesAddress(t *testing.T) {
+	addr := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	other := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	tests := []struct {
+		name   string
+		filter interface{}
+		log    common.Address
+		want   bool
+	}{
+		{"nil matches anything", nil, addr, true},
+		{"single string match", "0x1111111111111111111111111111111111111111", addr, true},
+		{"single string mismatch", "0x1111111111111111111111111111111111111111", other, false},
+		{"empty array is wildcard", []interface{}{}, addr, true},
+		{"array OR match", []interface{}{"0x2222222222222222222222222222222222222222", "0x1111111111111111111111111111111111111111"}, addr, true},
+		{"array OR no match", []interface{}{"0x2222222222222222222222222222222222222222"}, addr, false},
+		{"unrecognized type is wildcard", 42, addr, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesAddress(tt.filter, tt.log); got != tt.want {
+				t.Errorf("matchesAddress(%v, %v) = %v, want %v", tt.filter, tt.log, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchesTopics(t *testing.T) {
+	topicA := common.HexToHash("0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	topicB := common.HexToHash("0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+	logTopics := []common.Hash{topicA, topicB}
+
+	tests := []struct {
+		name   string
+		filter []interface{}
+		want   bool
+	}{
+		{"empty filter is wildcard", nil, true},
+		{"nil position is wildcard", []interface{}{nil, nil}, true},
+		{"exact AND match", []interface{}{topicA.Hex(), topicB.Hex()}, true},
+		{"AND mismatch on second position", []interface{}{topicA.Hex(), topicA.Hex()}, false},
+		{"OR within a position", []interface{}{[]interface{}{topicB.Hex(), topicA.Hex()}}, true},
+		{"OR within a position no match", []interface{}{[]interface{}{topicB.Hex()}}, false},
+		{"empty OR slice is wildcard", []interface{}{[]interface{}{}}, true},
+		{"more filter positions than log topics", []interface{}{topicA.Hex(), topicB.Hex(), topicA.Hex()}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesTopics(tt.filter, logTopics); got != tt.want {
+				t.Errorf("matchesTopics(%v, %v) = %v, want %v", tt.filter, logTopics, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogFilterCriteriaMatches(t *testing.T) {
+	addr := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	l := types.Log{Address: addr, Topics: []common.Hash{common.HexToHash("0xaa")}}
+
+	var nilCriteria *logFilterCriteria
+	if !nilCriteria.matches(l) {
+		t.Errorf("nil *logFilterCriteria should match anything")
+	}
+
+	criteria := &logFilterCriteria{Address: addr.Hex()}
+	if !criteria.matches(l) {
+		t.Errorf("criteria matching the log's address should match")
+	}
+
+	criteria = &logFilterCriteria{Address: "0x2222222222222222222222222222222222222222"}
+	if criteria.matches(l) {
+		t.Errorf("criteria for a different address should not match")
+	}
+}